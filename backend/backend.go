@@ -0,0 +1,43 @@
+// Package backend provides a pluggable interface for running sync/dedupe
+// operations against remote and local storage without shelling out to the
+// rclone binary. Concrete implementations wrap the rclone Go SDK directly
+// (see rclone.go) so callers get a real context.Context, typed errors, and
+// the ability to run several transfers concurrently.
+package backend
+
+import "context"
+
+// SyncOptions configures a Sync call. It mirrors the flags that used to be
+// passed on the rclone command line.
+type SyncOptions struct {
+	// DeleteDuring removes files from Dst that no longer exist in Src while
+	// the sync is in progress, rather than after it completes. When false,
+	// Sync only copies and removes nothing from Dst.
+	DeleteDuring bool
+	// FastList instructs backends that support it to list recursively in a
+	// single request instead of walking directory-by-direcory.
+	FastList bool
+	// BwLimitBytesPerSec caps transfer throughput. Zero means unlimited.
+	BwLimitBytesPerSec int64
+	// MultiThreadStreams is the number of concurrent streams used for a
+	// single large file transfer, where the backend supports it.
+	MultiThreadStreams int
+}
+
+// Backend is implemented by anything capable of syncing, deduping, and
+// transferring files for a storage remote. It is modeled on rclone's
+// fs/operations API so an implementation can be a thin wrapper around it.
+type Backend interface {
+	// Sync makes dst match src, per opts.
+	Sync(ctx context.Context, src, dst string, opts SyncOptions) error
+	// Dedupe resolves duplicate files found under path.
+	Dedupe(ctx context.Context, path string) error
+	// List returns the relative paths of entries found under path.
+	List(ctx context.Context, path string) ([]string, error)
+	// Put uploads the local file at localPath to remotePath.
+	Put(ctx context.Context, localPath, remotePath string) error
+	// Get downloads remotePath to the local file at localPath.
+	Get(ctx context.Context, remotePath, localPath string) error
+	// Hash returns the backend's preferred content hash for path.
+	Hash(ctx context.Context, path string) (string, error)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// errTransient wraps errors worth retrying: transient I/O errors and any
+// wrapped 5xx-equivalents reported by remote backends.
+var errTransient = errors.New("transient error")
+
+// retryConfig controls the exponential backoff used when retrying a
+// transient chunk-transfer failure.
+type retryConfig struct {
+	MaxAttempts int
+	Base        time.Duration
+	Factor      float64
+	Cap         time.Duration
+}
+
+var defaultRetryConfig = retryConfig{
+	MaxAttempts: 5,
+	Base:        time.Second,
+	Factor:      2,
+	Cap:         60 * time.Second,
+}
+
+// withBackoff calls fn until it succeeds, fn returns a non-transient error,
+// or cfg.MaxAttempts is exhausted. Each retry waits base*factor^(attempt-1),
+// capped at cfg.Cap, plus up to 20% jitter.
+func withBackoff(cfg retryConfig, onRetry func(attempt int, err error), fn func() error) error {
+	wait := cfg.Base
+	var err error
+	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if !errors.Is(err, errTransient) || attempt == cfg.MaxAttempts {
+			return err
+		}
+		if onRetry != nil {
+			onRetry(attempt, err)
+		}
+
+		jitter := time.Duration(rand.Int63n(int64(wait)/5 + 1))
+		time.Sleep(wait + jitter)
+
+		wait = time.Duration(float64(wait) * cfg.Factor)
+		if wait > cfg.Cap {
+			wait = cfg.Cap
+		}
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func fastRetryConfig(maxAttempts int) retryConfig {
+	return retryConfig{
+		MaxAttempts: maxAttempts,
+		Base:        time.Millisecond,
+		Factor:      2,
+		Cap:         10 * time.Millisecond,
+	}
+}
+
+func TestWithBackoffSucceedsAfterTransientErrors(t *testing.T) {
+	calls := 0
+	var retries []int
+	err := withBackoff(fastRetryConfig(5), func(attempt int, err error) {
+		retries = append(retries, attempt)
+	}, func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("%w: flaky", errTransient)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withBackoff() error = %v, want nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+	if want := []int{1, 2}; !equalInts(retries, want) {
+		t.Errorf("retries = %v, want %v", retries, want)
+	}
+}
+
+func TestWithBackoffStopsOnNonTransientError(t *testing.T) {
+	wantErr := errors.New("permanent failure")
+	calls := 0
+	err := withBackoff(fastRetryConfig(5), nil, func() error {
+		calls++
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("withBackoff() error = %v, want %v", err, wantErr)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (no retries for a non-transient error)", calls)
+	}
+}
+
+func TestWithBackoffExhaustsMaxAttempts(t *testing.T) {
+	calls := 0
+	err := withBackoff(fastRetryConfig(3), nil, func() error {
+		calls++
+		return fmt.Errorf("%w: still flaky", errTransient)
+	})
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("withBackoff() error = %v, want errTransient", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3 (MaxAttempts)", calls)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
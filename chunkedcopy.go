@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// defaultChunkSize is the amount of data streamed per chunk when copying a
+// file, and the granularity at which a resumable copy can restart.
+const defaultChunkSize = 8 * 1024 * 1024 // 8 MiB
+
+// partState is the sidecar recorded next to an in-progress copy so a re-run
+// can resume from the last good offset rather than starting over. HashState
+// is the marshaled state of the incremental SHA-256 so resuming doesn't
+// require re-hashing bytes already written.
+type partState struct {
+	DestPath     string `json:"dest_path"`
+	BytesWritten int64  `json:"bytes_written"`
+	HashState    []byte `json:"hash_state"`
+}
+
+func partPath(dst string) string { return dst + ".part" }
+
+func loadPartState(dst string) (*partState, error) {
+	data, err := os.ReadFile(partPath(dst))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st partState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func savePartState(dst string, st partState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(partPath(dst), data, 0644)
+}
+
+func clearPartState(dst string) error {
+	err := os.Remove(partPath(dst))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// copyFileChunked streams src to dst in chunkSize pieces, computing the
+// SHA-256 of the data as it goes so callers never need a second pass to
+// verify it. If dst already has an in-progress copy recorded in its .part
+// sidecar, the copy resumes from the last good offset instead of starting
+// over. Each chunk transfer is retried with exponential backoff on
+// transient I/O errors; prog, if non-nil, is notified of progress and
+// retries.
+func copyFileChunked(src, dst string, chunkSize int64, prog Progress) (string, error) {
+	if prog == nil {
+		prog = noopProgress{}
+	}
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+	total := srcInfo.Size()
+
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer sourceFile.Close()
+
+	destFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer destFile.Close()
+
+	hasher := sha256.New()
+	var offset int64
+
+	if st, err := loadPartState(dst); err != nil {
+		return "", err
+	} else if st != nil && st.DestPath == dst {
+		if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(st.HashState); err != nil {
+			return "", fmt.Errorf("resuming copy of %s: %w", src, err)
+		}
+		offset = st.BytesWritten
+	}
+
+	buf := make([]byte, chunkSize)
+	for offset < total {
+		var n int
+		retryErr := withBackoff(defaultRetryConfig, func(attempt int, err error) {
+			prog.OnRetry(dst, attempt, err)
+		}, func() error {
+			if _, err := sourceFile.Seek(offset, io.SeekStart); err != nil {
+				return fmt.Errorf("%w: seeking %s: %v", errTransient, src, err)
+			}
+			var readErr error
+			n, readErr = io.ReadFull(sourceFile, buf)
+			if readErr != nil && !errors.Is(readErr, io.ErrUnexpectedEOF) && !errors.Is(readErr, io.EOF) {
+				return fmt.Errorf("%w: reading %s: %v", errTransient, src, readErr)
+			}
+			if _, err := destFile.WriteAt(buf[:n], offset); err != nil {
+				return fmt.Errorf("%w: writing %s: %v", errTransient, dst, err)
+			}
+			return nil
+		})
+		if retryErr != nil {
+			return "", retryErr
+		}
+
+		chunk := buf[:n]
+		hasher.Write(chunk)
+		offset += int64(n)
+
+		hashState, err := hasher.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return "", err
+		}
+		if err := savePartState(dst, partState{DestPath: dst, BytesWritten: offset, HashState: hashState}); err != nil {
+			return "", err
+		}
+
+		prog.OnChunk(dst, offset, total)
+	}
+
+	if err := destFile.Sync(); err != nil {
+		return "", err
+	}
+	if err := clearPartState(dst); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
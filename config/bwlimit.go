@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BwLimitPoint is one point in a time-of-day bandwidth schedule, using
+// rclone's own --bwlimit schedule syntax: space-separated "HH:MM,value"
+// pairs, e.g. "08:00,1M 20:00,off".
+type BwLimitPoint struct {
+	Minute int   // minutes since midnight
+	Bytes  int64 // 0 means unlimited ("off")
+}
+
+// ParseBwLimitSchedule parses s, which may be:
+//   - empty, meaning unlimited at all times
+//   - a flat value applied at all times, either a plain size ("20M",
+//     "off") or a "min:max" range ("20M:2G")
+//   - a time-of-day schedule of space-separated "HH:MM,value" pairs, e.g.
+//     "08:00,1M 20:00,off", where value is itself a plain size or range
+//
+// It returns time-ordered points; a flat value parses to a single point
+// at minute 0.
+func ParseBwLimitSchedule(s string) ([]BwLimitPoint, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	if !strings.ContainsAny(s, " ,") {
+		value, err := parseBwLimitValue(s)
+		if err != nil {
+			return nil, err
+		}
+		return []BwLimitPoint{{Minute: 0, Bytes: value}}, nil
+	}
+
+	fields := strings.Fields(s)
+	points := make([]BwLimitPoint, 0, len(fields))
+	for _, field := range fields {
+		parts := strings.SplitN(field, ",", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("config: invalid bwlimit schedule entry %q", field)
+		}
+
+		minute, err := parseTimeOfDay(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseBwLimitValue(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		points = append(points, BwLimitPoint{Minute: minute, Bytes: value})
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].Minute < points[j].Minute })
+	return points, nil
+}
+
+func parseTimeOfDay(s string) (int, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("config: invalid time of day %q", s)
+	}
+	h, err := strconv.Atoi(hh)
+	if err != nil {
+		return 0, fmt.Errorf("config: invalid time of day %q: %w", s, err)
+	}
+	m, err := strconv.Atoi(mm)
+	if err != nil {
+		return 0, fmt.Errorf("config: invalid time of day %q: %w", s, err)
+	}
+	return h*60 + m, nil
+}
+
+// parseBwLimitValue parses one bandwidth value: "off", a plain size such
+// as "20M", or a "min:max" range such as "20M:2G". A range resolves to its
+// upper bound, since SyncOptions carries a single bytes-per-second cap
+// rather than separate upload/download rates.
+func parseBwLimitValue(s string) (int64, error) {
+	if lo, hi, ok := strings.Cut(s, ":"); ok {
+		if _, err := parseBwLimitSize(lo); err != nil {
+			return 0, err
+		}
+		return parseBwLimitSize(hi)
+	}
+	return parseBwLimitSize(s)
+}
+
+func parseBwLimitSize(s string) (int64, error) {
+	if strings.EqualFold(s, "off") {
+		return 0, nil
+	}
+
+	multiplier := float64(1)
+	switch suffix := s[len(s)-1:]; suffix {
+	case "K", "k":
+		multiplier = 1024
+		s = s[:len(s)-1]
+	case "M", "m":
+		multiplier = 1024 * 1024
+		s = s[:len(s)-1]
+	case "G", "g":
+		multiplier = 1024 * 1024 * 1024
+		s = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("config: invalid bwlimit value %q: %w", s, err)
+	}
+	return int64(value * multiplier), nil
+}
+
+// BwLimitAt returns the bandwidth limit in effect at t, in bytes per
+// second, per schedule. It is the value attached to the latest point not
+// after t's time of day, wrapping around midnight. An empty schedule means
+// unlimited.
+func BwLimitAt(schedule []BwLimitPoint, t time.Time) int64 {
+	if len(schedule) == 0 {
+		return 0
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	limit := schedule[len(schedule)-1].Bytes
+	for _, p := range schedule {
+		if p.Minute > minute {
+			break
+		}
+		limit = p.Bytes
+	}
+	return limit
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBwLimitScheduleEmpty(t *testing.T) {
+	schedule, err := ParseBwLimitSchedule("")
+	if err != nil {
+		t.Fatalf("ParseBwLimitSchedule(\"\") error = %v", err)
+	}
+	if schedule != nil {
+		t.Errorf("schedule = %v, want nil", schedule)
+	}
+}
+
+func TestParseBwLimitScheduleSortsAndConverts(t *testing.T) {
+	schedule, err := ParseBwLimitSchedule("20:00,off 08:00,1M")
+	if err != nil {
+		t.Fatalf("ParseBwLimitSchedule() error = %v", err)
+	}
+	want := []BwLimitPoint{
+		{Minute: 8 * 60, Bytes: 1024 * 1024},
+		{Minute: 20 * 60, Bytes: 0},
+	}
+	if len(schedule) != len(want) {
+		t.Fatalf("schedule = %v, want %v", schedule, want)
+	}
+	for i := range want {
+		if schedule[i] != want[i] {
+			t.Errorf("schedule[%d] = %v, want %v", i, schedule[i], want[i])
+		}
+	}
+}
+
+func TestParseBwLimitScheduleFlatValue(t *testing.T) {
+	schedule, err := ParseBwLimitSchedule("20M")
+	if err != nil {
+		t.Fatalf("ParseBwLimitSchedule(\"20M\") error = %v", err)
+	}
+	want := []BwLimitPoint{{Minute: 0, Bytes: 20 * 1024 * 1024}}
+	if len(schedule) != 1 || schedule[0] != want[0] {
+		t.Errorf("schedule = %v, want %v", schedule, want)
+	}
+}
+
+func TestParseBwLimitScheduleFlatOff(t *testing.T) {
+	schedule, err := ParseBwLimitSchedule("off")
+	if err != nil {
+		t.Fatalf("ParseBwLimitSchedule(\"off\") error = %v", err)
+	}
+	want := []BwLimitPoint{{Minute: 0, Bytes: 0}}
+	if len(schedule) != 1 || schedule[0] != want[0] {
+		t.Errorf("schedule = %v, want %v", schedule, want)
+	}
+}
+
+func TestParseBwLimitScheduleFlatRange(t *testing.T) {
+	schedule, err := ParseBwLimitSchedule("20M:2G")
+	if err != nil {
+		t.Fatalf("ParseBwLimitSchedule(\"20M:2G\") error = %v", err)
+	}
+	want := []BwLimitPoint{{Minute: 0, Bytes: 2 * 1024 * 1024 * 1024}}
+	if len(schedule) != 1 || schedule[0] != want[0] {
+		t.Errorf("schedule = %v, want %v", schedule, want)
+	}
+}
+
+func TestParseBwLimitScheduleRangeInSchedule(t *testing.T) {
+	schedule, err := ParseBwLimitSchedule("08:00,20M:2G")
+	if err != nil {
+		t.Fatalf("ParseBwLimitSchedule() error = %v", err)
+	}
+	want := []BwLimitPoint{{Minute: 8 * 60, Bytes: 2 * 1024 * 1024 * 1024}}
+	if len(schedule) != 1 || schedule[0] != want[0] {
+		t.Errorf("schedule = %v, want %v", schedule, want)
+	}
+}
+
+func TestParseBwLimitScheduleInvalidEntry(t *testing.T) {
+	if _, err := ParseBwLimitSchedule("not-a-valid-entry"); err == nil {
+		t.Error("ParseBwLimitSchedule() error = nil, want error for malformed entry")
+	}
+}
+
+func TestBwLimitAtWrapsAroundMidnight(t *testing.T) {
+	schedule, err := ParseBwLimitSchedule("08:00,1M 20:00,off")
+	if err != nil {
+		t.Fatalf("ParseBwLimitSchedule() error = %v", err)
+	}
+
+	cases := []struct {
+		name string
+		at   time.Time
+		want int64
+	}{
+		{"before first point falls back to the last point of the prior day", time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC), 0},
+		{"exactly on the first point", time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC), 1024 * 1024},
+		{"between points", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), 1024 * 1024},
+		{"exactly on the last point", time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC), 0},
+		{"after the last point, still wrapped to it", time.Date(2024, 1, 1, 23, 59, 0, 0, time.UTC), 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := BwLimitAt(schedule, c.at); got != c.want {
+				t.Errorf("BwLimitAt() = %d, want %d", got, c.want)
+			}
+		})
+	}
+}
+
+func TestBwLimitAtEmptyScheduleIsUnlimited(t *testing.T) {
+	if got := BwLimitAt(nil, time.Now()); got != 0 {
+		t.Errorf("BwLimitAt(nil, ...) = %d, want 0 (unlimited)", got)
+	}
+}
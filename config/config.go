@@ -0,0 +1,78 @@
+// Package config loads Sinki Backups' sync policy from ./config/sinki.yaml,
+// replacing the hard-coded rclone flags the project used to ship with.
+package config
+
+import (
+	"os"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy controls bandwidth, concurrency, and sync behavior for one remote.
+type Policy struct {
+	DeleteDuring       bool   `yaml:"delete_during"`
+	FastList           bool   `yaml:"fast_list"`
+	MultiThreadStreams int    `yaml:"multi_thread_streams"`
+	// BwLimit is a flat value ("20M", "off"), a "min:max" range
+	// ("20M:2G"), or a time-of-day schedule in rclone's own --bwlimit
+	// syntax, e.g. "08:00,1M 20:00,off". See ParseBwLimitSchedule.
+	BwLimit string `yaml:"bwlimit"`
+}
+
+// RemoteOverride replaces Config.Default's policy for one named remote.
+type RemoteOverride struct {
+	Remote string `yaml:"remote"`
+	Policy Policy `yaml:"policy"`
+}
+
+// Config is the parsed contents of sinki.yaml.
+type Config struct {
+	Default Policy           `yaml:"default"`
+	Remotes []RemoteOverride `yaml:"remotes"`
+}
+
+// Load reads and parses the YAML config at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// PolicyFor returns the policy configured for remote, falling back to
+// Default if remote has no override.
+func (c *Config) PolicyFor(remote string) Policy {
+	for _, r := range c.Remotes {
+		if r.Remote == remote {
+			return r.Policy
+		}
+	}
+	return c.Default
+}
+
+// Store holds the active Config behind an atomic pointer, so a SIGHUP
+// reload can swap it in without readers needing to lock.
+type Store struct {
+	v atomic.Pointer[Config]
+}
+
+// NewStore returns a Store initialized with cfg.
+func NewStore(cfg *Config) *Store {
+	s := &Store{}
+	s.v.Store(cfg)
+	return s
+}
+
+// Get returns the currently active Config.
+func (s *Store) Get() *Config { return s.v.Load() }
+
+// Set replaces the active Config, taking effect for any read after it
+// returns.
+func (s *Store) Set(cfg *Config) { s.v.Store(cfg) }
@@ -0,0 +1,30 @@
+//go:build darwin
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflinkCopy attempts a copy-on-write clone of src onto dst via
+// clonefile(2), which APFS implements. clonefile requires dst not to
+// already exist, so a stale destination from a previous attempt is removed
+// first.
+func reflinkCopy(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err := unix.Clonefile(src, dst, 0)
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, unix.EXDEV) || errors.Is(err, unix.ENOTSUP) {
+		return errReflinkUnsupported
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// fsctlDuplicateExtentsToFile is FSCTL_DUPLICATE_EXTENTS_TO_FILE, the
+// control code ReFS uses to clone a range of extents from one file onto
+// another without copying the underlying data.
+const fsctlDuplicateExtentsToFile = 0x00098344
+
+// duplicateExtentsData mirrors the DUPLICATE_EXTENTS_DATA struct expected
+// by FSCTL_DUPLICATE_EXTENTS_TO_FILE.
+type duplicateExtentsData struct {
+	FileHandle       windows.Handle
+	SourceFileOffset int64
+	TargetFileOffset int64
+	ByteLength       int64
+}
+
+// reflinkCopy attempts a copy-on-write clone of src onto dst using
+// FSCTL_DUPLICATE_EXTENTS_TO_FILE, which ReFS implements. Volumes that
+// don't support it (most notably NTFS) report ERROR_INVALID_FUNCTION.
+func reflinkCopy(src, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	if err := dstFile.Truncate(srcInfo.Size()); err != nil {
+		return err
+	}
+
+	dup := duplicateExtentsData{
+		FileHandle:       windows.Handle(srcFile.Fd()),
+		SourceFileOffset: 0,
+		TargetFileOffset: 0,
+		ByteLength:       srcInfo.Size(),
+	}
+
+	var bytesReturned uint32
+	err = windows.DeviceIoControl(
+		windows.Handle(dstFile.Fd()),
+		fsctlDuplicateExtentsToFile,
+		(*byte)(unsafe.Pointer(&dup)),
+		uint32(unsafe.Sizeof(dup)),
+		nil, 0,
+		&bytesReturned, nil,
+	)
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, windows.ERROR_INVALID_FUNCTION) || errors.Is(err, windows.ERROR_NOT_SUPPORTED) {
+		return errReflinkUnsupported
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+// Package logging provides structured logging for Sinki Backups, built on
+// log/slog. It writes JSON or text events to a size- and time-rotated file
+// under the log directory, and derives failed.log as a filtered view of
+// that same stream rather than a separate write path.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Config controls where logs are written and how they are rotated.
+type Config struct {
+	// Dir is the directory logs are written under, e.g. "./logs".
+	Dir string
+	// Format is "text" or "json".
+	Format string
+	Level  slog.Level
+	// MaxSizeBytes rotates the active log file once it would exceed this
+	// size. Zero disables size-based rotation.
+	MaxSizeBytes int64
+	// MaxAge rotates the active log file once it has been open longer
+	// than this. Zero disables age-based rotation.
+	MaxAge time.Duration
+	// RetainedFiles caps how many rotated files are kept; the oldest are
+	// removed once the cap is exceeded.
+	RetainedFiles int
+}
+
+// DefaultConfig matches the layout Sinki Backups has always used:
+// ./logs/sinki.log for the main stream, rotated daily or past 50MB, with
+// five rotations retained.
+var DefaultConfig = Config{
+	Dir:           "./logs",
+	Format:        "text",
+	Level:         slog.LevelInfo,
+	MaxSizeBytes:  50 * 1024 * 1024,
+	MaxAge:        24 * time.Hour,
+	RetainedFiles: 5,
+}
+
+// New builds a *slog.Logger that writes to cfg.Dir/sinki.log. Any record
+// at slog.LevelError or above is additionally appended to cfg.Dir/failed.log,
+// so that file stays a live filtered view of the main stream instead of a
+// write path of its own.
+func New(cfg Config) (*slog.Logger, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	main := newRotatingWriter(filepath.Join(cfg.Dir, "sinki.log"), cfg)
+	failed := newAppendWriter(filepath.Join(cfg.Dir, "failed.log"))
+
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	var mainHandler slog.Handler
+	if cfg.Format == "json" {
+		mainHandler = slog.NewJSONHandler(main, opts)
+	} else {
+		mainHandler = slog.NewTextHandler(main, opts)
+	}
+	failedHandler := slog.NewTextHandler(failed, &slog.HandlerOptions{Level: slog.LevelError})
+
+	return slog.New(&failedViewHandler{main: mainHandler, failed: failedHandler}), nil
+}
+
+// Must is a helper for call sites (typically main) that want to fail fast
+// if the log directory can't be created.
+func Must(logger *slog.Logger, err error) *slog.Logger {
+	if err != nil {
+		panic(err)
+	}
+	return logger
+}
+
+// NewCorrelationID returns a short random hex identifier. Attaching it to
+// every log line belonging to one operation (one CSV row, one storage
+// pair) lets that operation's events be grepped or filtered as a unit.
+func NewCorrelationID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// failedViewHandler forwards every record to main, and additionally to
+// failed for anything at slog.LevelError or above.
+type failedViewHandler struct {
+	main   slog.Handler
+	failed slog.Handler
+}
+
+func (h *failedViewHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.main.Enabled(ctx, level) || h.failed.Enabled(ctx, level)
+}
+
+func (h *failedViewHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.main.Enabled(ctx, r.Level) {
+		if err := h.main.Handle(ctx, r); err != nil {
+			return err
+		}
+	}
+	if r.Level >= slog.LevelError && h.failed.Enabled(ctx, r.Level) {
+		return h.failed.Handle(ctx, r.Clone())
+	}
+	return nil
+}
+
+func (h *failedViewHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &failedViewHandler{main: h.main.WithAttrs(attrs), failed: h.failed.WithAttrs(attrs)}
+}
+
+func (h *failedViewHandler) WithGroup(name string) slog.Handler {
+	return &failedViewHandler{main: h.main.WithGroup(name), failed: h.failed.WithGroup(name)}
+}
@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rotatingWriter is an io.Writer that rotates the underlying file once it
+// would exceed cfg.MaxSizeBytes or has been open longer than cfg.MaxAge,
+// keeping cfg.RetainedFiles old copies suffixed with a rotation timestamp.
+type rotatingWriter struct {
+	path string
+	cfg  Config
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+func newRotatingWriter(path string, cfg Config) *rotatingWriter {
+	return &rotatingWriter{path: path, cfg: cfg}
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.ensureOpen(); err != nil {
+		return 0, err
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotatingWriter) ensureOpen() error {
+	if w.file != nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.file = f
+	w.size = info.Size()
+	w.opened = info.ModTime()
+	return nil
+}
+
+func (w *rotatingWriter) shouldRotate(next int64) bool {
+	if w.cfg.MaxSizeBytes > 0 && w.size+next > w.cfg.MaxSizeBytes {
+		return true
+	}
+	if w.cfg.MaxAge > 0 && time.Since(w.opened) > w.cfg.MaxAge {
+		return true
+	}
+	return false
+}
+
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	rotatedPath := fmt.Sprintf("%s.%s", w.path, time.Now().UTC().Format("20060102T150405"))
+	if err := os.Rename(w.path, rotatedPath); err != nil {
+		return err
+	}
+	if err := w.pruneOld(); err != nil {
+		return err
+	}
+	return w.ensureOpen()
+}
+
+func (w *rotatingWriter) pruneOld() error {
+	matches, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return err
+	}
+	if len(matches) <= w.cfg.RetainedFiles {
+		return nil
+	}
+
+	sort.Strings(matches) // the timestamp suffix sorts chronologically
+	for _, old := range matches[:len(matches)-w.cfg.RetainedFiles] {
+		if err := os.Remove(old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendWriter serializes writes to a single append-only file, used for
+// failed.log so concurrent backups don't interleave partial lines.
+type appendWriter struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newAppendWriter(path string) *appendWriter {
+	return &appendWriter{path: path}
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return 0, err
+		}
+		w.file = f
+	}
+	return w.file.Write(p)
+}
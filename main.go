@@ -1,20 +1,35 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/csv"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"sinki-backups/backend"
+	"sinki-backups/config"
+	"sinki-backups/logging"
+	"sinki-backups/scheduler"
 )
 
+// logger is the package-wide structured logger, replaced in main with one
+// writing to the rotating ./logs/sinki.log sink once the log directory is
+// known to exist.
+var logger = slog.Default()
+
 type Operation struct {
 	Type      string
 	SourceDir string
@@ -24,6 +39,10 @@ type Operation struct {
 type Storage struct {
 	Src string
 	Dst string
+	// Cron is this storage pair's schedule, in standard cron syntax (or
+	// one of the @every/@daily-style descriptors). Empty means
+	// defaultScheduleSpec.
+	Cron string
 }
 
 type FileInfo struct {
@@ -31,104 +50,123 @@ type FileInfo struct {
 	Size         int64     `json:"size"`
 	ModTime      time.Time `json:"mod_time"`
 	Hash         string    `json:"hash"`
+	// Reflinked records whether the backup copy of this file was made as a
+	// copy-on-write clone rather than a byte-for-byte copy. A later
+	// manifest build can trust a reflinked entry's Hash without rereading
+	// the file as long as ModTime hasn't changed.
+	Reflinked bool `json:"reflinked"`
+}
+
+// remoteNameFor returns the config remote name backing the given operation
+// type, so PreOperations can look up its Policy. Adding a new remote is a
+// matter of adding a case here rather than threading a new CSV Type string
+// through a shell-out switch.
+func remoteNameFor(opType string) (string, error) {
+	switch opType {
+	case "rclone-dedupe", "rclone-sync-onedrive":
+		return "onedrive", nil
+	case "rclone-sync-google":
+		return "gdrive", nil
+	default:
+		return "", fmt.Errorf("unknown operation: %s", opType)
+	}
+}
+
+func backendFor(remote, opType string) backend.Backend {
+	b := backend.NewRcloneBackend(remote)
+	b.AcknowledgeAbuse = opType == "rclone-sync-google"
+	b.OnedriveDelta = remote == "onedrive"
+	return b
 }
 
-func PreOperations() {
-	// open file
+// syncOptionsFor converts a config.Policy into backend.SyncOptions,
+// resolving its (possibly time-scheduled) bandwidth limit for now.
+func syncOptionsFor(p config.Policy) backend.SyncOptions {
+	schedule, err := config.ParseBwLimitSchedule(p.BwLimit)
+	if err != nil {
+		logger.Warn("policy.invalid_bwlimit", "bwlimit", p.BwLimit, "error", err)
+	}
+
+	return backend.SyncOptions{
+		DeleteDuring:       p.DeleteDuring,
+		FastList:           p.FastList,
+		MultiThreadStreams: p.MultiThreadStreams,
+		BwLimitBytesPerSec: config.BwLimitAt(schedule, time.Now()),
+	}
+}
+
+// PreOperations runs every operation listed in config/pre-operations.csv
+// concurrently, through the Backend interface, instead of shelling out to
+// the rclone binary for each row. Bandwidth, concurrency, and sync
+// behavior come from cfg rather than hard-coded flags.
+func PreOperations(ctx context.Context, cfg *config.Config) error {
 	f, err := os.Open("./config/pre-operations.csv")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	// remember to close the file at the end of the program
 	defer f.Close()
 
-	// read csv values using csv.Reader
 	csvReader := csv.NewReader(f)
-
-	// Skip the header if there is one
 	if _, err := csvReader.Read(); err != nil {
-		log.Fatal("Error reading CSV header:", err)
+		return fmt.Errorf("reading CSV header: %w", err)
 	}
 
-	// Define common arguments for rclone sync operations
-	commonSyncArgs := []string{"--bwlimit=20M:2G", "--fast-list", "--multi-thread-streams=10", "--delete-during", "-P"}
+	g, ctx := errgroup.WithContext(ctx)
 
 	for {
-		// read each record from csv
 		record, err := csvReader.Read()
 		if errors.Is(err, io.EOF) {
 			break
 		}
 		if err != nil {
-			log.Println("Error reading CSV record:", err)
+			logger.Warn("pre-operations.csv_record_error", "error", err)
 			continue
 		}
 
-		// create operation
 		op := Operation{
 			Type:      record[0],
 			SourceDir: record[1],
 			DestDir:   record[2],
 		}
-		log.Println(op)
-
-		// Construct command arguments dynamically
-		var args []string
-		switch op.Type {
-		case "rclone-dedupe":
-			args = append(args, "dedupe", "rename")
-			if op.DestDir != "" {
-				args = append(args, op.DestDir)
-			}
-		case "rclone-sync-google":
-			args = append(args, "sync", "--drive-acknowledge-abuse")
-			args = append(args, commonSyncArgs...)
-			fmt.Println("this is the variable args:", args)
-			if op.SourceDir != "" {
-				args = append(args, op.SourceDir)
-			}
-			if op.DestDir != "" {
-				args = append(args, op.DestDir)
-			}
-			fmt.Println("this is the variable args:", args)
-		case "rclone-sync-onedrive":
-			args = append(args, "sync", "--onedrive-delta")
-			args = append(args, commonSyncArgs...)
-			if op.SourceDir != "" {
-				args = append(args, op.SourceDir)
-			}
-			if op.DestDir != "" {
-				args = append(args, op.DestDir)
-			}
-		default:
-			log.Printf("Unknown operation: %s", op.Type)
-			continue
-		}
+		correlationID := logging.NewCorrelationID()
+		opLogger := logger.With("correlation_id", correlationID, "op", op.Type, "src", op.SourceDir, "dst", op.DestDir)
+		opLogger.Info("op.start")
 
-		// Print the args variable and the message
-		fmt.Println("this is the variable args:", args)
-
-		// Execute the command
-		cmd := exec.Command("rclone", args...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-
-		log.Printf("Executing: %s", cmd.String())
-		if err := cmd.Run(); err != nil {
-			log.Printf("Error executing command: %v", err)
+		remote, err := remoteNameFor(op.Type)
+		if err != nil {
+			opLogger.Error("op.unknown_type", "error", err)
+			continue
 		}
+		b := backendFor(remote, op.Type)
+		opts := syncOptionsFor(cfg.PolicyFor(remote))
+
+		g.Go(func() error {
+			var err error
+			if op.Type == "rclone-dedupe" {
+				err = b.Dedupe(ctx, op.DestDir)
+			} else {
+				err = b.Sync(ctx, op.SourceDir, op.DestDir, opts)
+			}
+			if err != nil {
+				opLogger.Error("op.failed", "error", err)
+			} else {
+				opLogger.Info("op.done")
+			}
+			return err
+		})
 	}
+
+	return g.Wait()
 }
 
 func GetFilesInfo(hashYN string, file string, line string) (FileInfo, error) {
 	var hash string
 	if hashYN == "Y" {
-		data, err := os.ReadFile(file)
+		h, err := getFileHash(file)
 		if err != nil {
 			return FileInfo{}, err
 		}
-		hashBytes := sha256.Sum256(data)
-		hash = hex.EncodeToString(hashBytes[:])
+		hash = h
 	}
 
 	relativePath := strings.TrimPrefix(file, line)
@@ -148,23 +186,30 @@ func GetFilesInfo(hashYN string, file string, line string) (FileInfo, error) {
 func ProcessLine(srcDir string) string {
 	manifest := fmt.Sprintf("%s%s.manifest", srcDir[:1], filepath.Base(srcDir))
 	manifest = strings.ReplaceAll(manifest, " ", "_")
-	fmt.Println("Manifest:", manifest)
 	manifestFilePath := filepath.Join(".", "logs", manifest)
+	logger.Debug("manifest.path", "manifest", manifest, "path", manifestFilePath)
 	return manifestFilePath
 }
 
-func AddBackup(file, srcDir, dstDir, expectedHash, logDir string) (string, error) {
+// AddBackup backs up file into dstDir, preferring a reflink clone when the
+// destination volume supports one and falling back to a verified chunked
+// copy otherwise. It reports whether the copy was reflinked, since a
+// reflinked copy's Hash can be trusted without rereading the file as long
+// as its ModTime hasn't changed.
+func AddBackup(file, srcDir, dstDir, expectedHash string) (string, bool, error) {
+	opLogger := logger.With("correlation_id", logging.NewCorrelationID(), "file", file)
+
 	// Check if the path is a file
 	fileInfo, err := os.Stat(file)
 	if err != nil || fileInfo.IsDir() {
-		fmt.Println("Skipping directory:", file)
-		return "", nil
+		opLogger.Debug("backup.skip_directory")
+		return "", false, nil
 	}
 
 	// Get the full path of the file
 	fullPath, err := filepath.Abs(file)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	// Calculate the relative path
@@ -179,87 +224,108 @@ func AddBackup(file, srcDir, dstDir, expectedHash, logDir string) (string, error
 	if _, err := os.Stat(destinationDir); os.IsNotExist(err) {
 		err = os.MkdirAll(destinationDir, os.ModePerm)
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
 	}
 
 	maxRetries := 3
 	attempt := 0
 	success := false
+	reflinked := false
 
 	for attempt < maxRetries && !success {
-		// Copy the file to the backup location
-		err = copyFile(fullPath, destinationPath)
+		// Copy the file to the backup location, preferring a CoW reflink
+		// and falling back to a resumable chunked copy otherwise. A
+		// reflinked copy shares data with the source, so it needs no
+		// separate hash verification.
+		copiedFileHash, wasReflinked, err := copyFileWithOptions(fullPath, destinationPath, CopyOptions{PreferReflink: true})
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
 
-		// Verify the hash sum of the copied file
-		copiedFileHash, err := getFileHash(destinationPath)
-		if err != nil {
-			return "", err
-		}
-
-		if copiedFileHash == expectedHash {
-			fmt.Println("File copied and verified successfully:", file)
+		if wasReflinked || copiedFileHash == expectedHash {
+			opLogger.Info("backup.copy_verified", "reflinked", wasReflinked)
+			reflinked = wasReflinked
 			time.Sleep(25 * time.Millisecond)
 
 			// Compress the file using 7zip
 			compressedFilePath := destinationPath + ".7z"
 			cmd := exec.Command("7z", "a", "-t7z", "-m0=lzma2", "-mx=9", "-mfb=64", "-md=32m", "-ms=on", compressedFilePath, destinationPath)
-			fmt.Println("Compressing file:", file)
+			opLogger.Debug("backup.compress", "archive", compressedFilePath)
 			err = cmd.Run()
 			if err != nil {
-				return "", err
+				return "", false, err
 			}
 			success = true
 		} else {
-			fmt.Printf("Hash mismatch for file: %s. Attempt %d of %d.\n", file, attempt+1, maxRetries)
+			opLogger.Warn("backup.hash_mismatch", "attempt", attempt+1, "max_retries", maxRetries)
+			if err := clearPartState(destinationPath); err != nil {
+				return "", false, err
+			}
 			attempt++
 		}
 	}
 
 	if !success {
-		// Log the failure
-		logFilePath := filepath.Join(logDir, "failed.log")
-		logMessage := fmt.Sprintf("Failed to copy and verify file: %s after %d attempts.", file, maxRetries)
-		err = appendToFile(logFilePath, logMessage)
-		if err != nil {
-			return "", err
-		}
-		fmt.Println(logMessage)
-		return "", fmt.Errorf(logMessage)
+		err := fmt.Errorf("failed to copy and verify file %s after %d attempts", file, maxRetries)
+		opLogger.Error("backup.failed", "error", err, "max_retries", maxRetries)
+		return "", false, err
 	}
 
-	return destinationPath, nil
+	return destinationPath, reflinked, nil
 }
 
-func GatherFileInfo(mainStorage []Storage) {
-	if len(mainStorage) == 0 {
-		fmt.Println("No data to process")
+// backupStorage builds a fresh manifest for one storage pair, diffs it
+// against the manifest left by the previous run, and backs up only the
+// files that were added or modified before persisting the new manifest.
+func backupStorage(storage Storage) {
+	src := storage.Src
+	dst := storage.Dst
+	opLogger := logger.With("correlation_id", logging.NewCorrelationID(), "src", src, "dst", dst)
+
+	base := filepath.Base(src)
+	manifestFilePath := filepath.Join("./logs", base+".manifest")
+
+	oldManifest, err := LoadManifest(manifestFilePath)
+	if err != nil {
+		opLogger.Error("manifest.load_failed", "path", manifestFilePath, "error", err)
+		return
+	}
+
+	newManifest, err := BuildManifest(src, "Y", oldManifest)
+	if err != nil {
+		opLogger.Error("manifest.build_failed", "error", err)
 		return
 	}
 
-	fmt.Println("This is me", mainStorage)
+	indexByPath := make(map[string]int, len(newManifest))
+	for i, fi := range newManifest {
+		indexByPath[fi.RelativePath] = i
+	}
+
+	added, removed, modified := DiffManifests(oldManifest, newManifest)
+	opLogger.Info("manifest.diff", "added", len(added), "removed", len(removed), "modified", len(modified))
 
-	for _, storage := range mainStorage[1:] {
-		src := storage.Src
-		dst := storage.Dst
-		fmt.Println("Source inside GatherFileInfo", src)
-		fmt.Println("Destination inside GatherFileInfo", dst)
-
-		base := filepath.Base(src)
-		manifestFileName := base + ".manifest"
-		manifestFilePath := filepath.Join("./logs", manifestFileName)
-
-		if _, err := os.Stat(manifestFilePath); os.IsNotExist(err) {
-			fmt.Printf("Manifest file does not exist: %s\n", manifestFilePath)
-			// Create the manifest file
-			fmt.Printf("Manifest file created: %s\n", manifestFilePath)
-		} else {
-			fmt.Printf("Manifest file exists: %s\n", manifestFilePath)
+	diffCSVPath := filepath.Join("./logs", base+".diff.csv")
+	if err := WriteDiffCSV(diffCSVPath, added, removed, modified); err != nil {
+		opLogger.Error("manifest.diff_report_failed", "path", diffCSVPath, "error", err)
+	}
+
+	for _, fi := range append(append([]FileInfo{}, added...), modified...) {
+		fullSrcPath := filepath.Join(src, fi.RelativePath)
+		_, reflinked, err := AddBackup(fullSrcPath, src, dst, fi.Hash)
+		if err != nil {
+			opLogger.Error("backup.failed", "file", fullSrcPath, "error", err)
+			continue
+		}
+		if idx, ok := indexByPath[fi.RelativePath]; ok {
+			newManifest[idx].Reflinked = reflinked
 		}
 	}
+
+	if err := SaveManifest(manifestFilePath, newManifest); err != nil {
+		opLogger.Error("manifest.save_failed", "path", manifestFilePath, "error", err)
+	}
 }
 
 func getFileHash(filePath string) (string, error) {
@@ -277,46 +343,12 @@ func getFileHash(filePath string) (string, error) {
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
-func appendToFile(filePath, text string) error {
-	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	_, err = f.WriteString(text + "\n")
-	return err
-}
-
-// copyFile copies a file from src to dst. If dst does not exist, it is created.
+// copyFile copies a file from src to dst, preferring a reflink clone when
+// the platform and destination volume support one and falling back to a
+// resumable chunked copy otherwise. If dst does not exist, it is created.
 func copyFile(src, dst string) error {
-	// Open the source file
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	// Create the destination file
-	destinationFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer destinationFile.Close()
-
-	// Copy the contents from source to destination
-	_, err = io.Copy(destinationFile, sourceFile)
-	if err != nil {
-		return err
-	}
-
-	// Flush the contents to disk
-	err = destinationFile.Sync()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	_, _, err := copyFileWithOptions(src, dst, CopyOptions{PreferReflink: true})
+	return err
 }
 
 func readCSV(filePath string) ([]Storage, error) {
@@ -334,26 +366,122 @@ func readCSV(filePath string) ([]Storage, error) {
 
 	var storages []Storage
 	for _, record := range records {
-		storages = append(storages, Storage{
+		storage := Storage{
 			Src: record[0],
 			Dst: record[1],
-		})
+		}
+		if len(record) > 2 {
+			storage.Cron = record[2]
+		}
+		storages = append(storages, storage)
 	}
 
 	return storages, nil
 }
 
+// defaultScheduleSpec is used for any storage row that doesn't set its own
+// Cron column.
+const defaultScheduleSpec = "@daily"
+
+const (
+	mainStoragesCSVPath = "./config/main-storages.csv"
+	sinkiConfigYAMLPath = "./config/sinki.yaml"
+)
+
+// buildJobs reads main-storages.csv and turns each row into a scheduler
+// job that runs PreOperations followed by a backup of that one storage
+// pair, on that row's own cron spec.
+func buildJobs(ctx context.Context, cfgStore *config.Store) ([]scheduler.Job, error) {
+	mainStorage, err := readCSV(mainStoragesCSVPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", mainStoragesCSVPath, err)
+	}
+	if len(mainStorage) == 0 {
+		logger.Info("build_jobs.no_data")
+		return nil, nil
+	}
+
+	var jobs []scheduler.Job
+	for _, storage := range mainStorage[1:] {
+		storage := storage
+		spec := storage.Cron
+		if spec == "" {
+			spec = defaultScheduleSpec
+		}
+
+		jobs = append(jobs, scheduler.Job{
+			Name: storage.Src,
+			Spec: spec,
+			Run: func(ctx context.Context) {
+				if err := PreOperations(ctx, cfgStore.Get()); err != nil {
+					logger.Error("main.pre_operations_failed", "error", err)
+				}
+				backupStorage(storage)
+			},
+		})
+	}
+
+	return jobs, nil
+}
+
+// main runs Sinki Backups as a long-lived daemon: each storage pair backs
+// up on its own cron schedule, and SIGHUP reloads sinki.yaml and
+// main-storages.csv without restarting the process.
 func main() {
-	filePath := "./config/main-storages.csv"
-	mainStorage, err := readCSV(filePath)
+	logger = logging.Must(logging.New(logging.DefaultConfig))
+
+	cfg, err := config.Load(sinkiConfigYAMLPath)
 	if err != nil {
-		log.Fatalf("Error reading CSV file: %v", err)
+		logger.Error("main.config_load_failed", "path", sinkiConfigYAMLPath, "error", err)
+		os.Exit(1)
 	}
-	//fmt.Print("mainStorage var in main", mainStorage)
-	GatherFileInfo(mainStorage)
+	cfgStore := config.NewStore(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	for _, mainStorages := range mainStorage {
-		log.Printf("Src: %s, Dst: %s", mainStorages.Src, mainStorages.Dst)
+	sched := scheduler.New(logger)
+	jobs, err := buildJobs(ctx, cfgStore)
+	if err != nil {
+		logger.Error("main.schedule_build_failed", "error", err)
+		os.Exit(1)
+	}
+	if err := sched.Reload(ctx, jobs); err != nil {
+		logger.Error("main.schedule_reload_failed", "error", err)
+		os.Exit(1)
 	}
+	sched.Start()
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+
+	for {
+		select {
+		case <-reload:
+			logger.Info("main.reload")
+
+			newCfg, err := config.Load(sinkiConfigYAMLPath)
+			if err != nil {
+				logger.Error("main.config_reload_failed", "path", sinkiConfigYAMLPath, "error", err)
+				continue
+			}
+			cfgStore.Set(newCfg)
 
+			jobs, err := buildJobs(ctx, cfgStore)
+			if err != nil {
+				logger.Error("main.schedule_build_failed", "error", err)
+				continue
+			}
+			if err := sched.Reload(ctx, jobs); err != nil {
+				logger.Error("main.schedule_reload_failed", "error", err)
+			}
+
+		case <-shutdown:
+			logger.Info("main.shutdown")
+			sched.Stop()
+			return
+		}
+	}
 }
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Manifest is a snapshot of every file under a source directory, sorted by
+// RelativePath so two manifests can be diffed by walking them in lockstep.
+type Manifest []FileInfo
+
+// defaultManifestWorkers bounds how many files are stat'd/hashed
+// concurrently while building a manifest.
+const defaultManifestWorkers = 8
+
+// BuildManifest walks srcDir and returns a Manifest describing every
+// non-directory entry under it. Hashing is performed per entry only when hashYN
+// is "Y"; pass "N" to build a cheaper size+mtime-only manifest. prev, the
+// manifest from the previous run (may be nil), lets a file that was
+// reflinked last time skip re-hashing as long as its ModTime hasn't
+// changed, since a reflinked copy is guaranteed identical to its source.
+func BuildManifest(srcDir, hashYN string, prev Manifest) (Manifest, error) {
+	prevByPath := make(map[string]FileInfo, len(prev))
+	for _, fi := range prev {
+		prevByPath[fi.RelativePath] = fi
+	}
+
+	var paths []string
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]FileInfo, len(paths))
+	errs := make([]error, len(paths))
+
+	sem := make(chan struct{}, defaultManifestWorkers)
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if hashYN == "Y" {
+				if fi, ok := reuseTrustedHash(path, srcDir, prevByPath); ok {
+					entries[i] = fi
+					return
+				}
+			}
+			entries[i], errs[i] = GetFilesInfo(hashYN, path, srcDir)
+		}(i, path)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].RelativePath < entries[j].RelativePath })
+	return Manifest(entries), nil
+}
+
+// reuseTrustedHash returns the previous manifest entry for path, with its
+// Size and ModTime refreshed from disk, if that entry was reflinked and its
+// ModTime hasn't changed since. This lets a reflinked file skip being
+// re-hashed, since a reflink clone is byte-identical to its source by
+// construction.
+func reuseTrustedHash(path, srcDir string, prevByPath map[string]FileInfo) (FileInfo, bool) {
+	relativePath := strings.TrimPrefix(path, srcDir)
+	prev, ok := prevByPath[relativePath]
+	if !ok || !prev.Reflinked || prev.Hash == "" {
+		return FileInfo{}, false
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil || !stat.ModTime().Equal(prev.ModTime) {
+		return FileInfo{}, false
+	}
+
+	return FileInfo{
+		RelativePath: relativePath,
+		Size:         stat.Size(),
+		ModTime:      prev.ModTime,
+		Hash:         prev.Hash,
+		Reflinked:    prev.Reflinked,
+	}, true
+}
+
+// SaveManifest writes m as newline-delimited JSON to path, plus a
+// path+".sha256" sidecar containing the checksum of the manifest file so a
+// later load can detect a truncated or corrupted manifest. The write is
+// atomic: m is written to a temp file in the same directory and renamed
+// into place.
+func SaveManifest(path string, m Manifest) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once the rename below succeeds
+
+	hasher := sha256.New()
+	enc := json.NewEncoder(io.MultiWriter(tmp, hasher))
+	for _, fi := range m {
+		if err := enc.Encode(fi); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	return os.WriteFile(path+".sha256", []byte(checksum+"\n"), 0644)
+}
+
+// LoadManifest reads a manifest previously written by SaveManifest,
+// verifying it against its path+".sha256" sidecar first so a truncated or
+// otherwise corrupted manifest is reported rather than silently partially
+// loaded. It returns a nil Manifest, not an error, if path does not exist
+// yet.
+func LoadManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	wantChecksum, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest checksum %s: %w", path+".sha256", err)
+	}
+	sum := sha256.Sum256(data)
+	gotChecksum := hex.EncodeToString(sum[:])
+	if gotChecksum != strings.TrimSpace(string(wantChecksum)) {
+		return nil, fmt.Errorf("manifest %s is corrupted: checksum does not match %s", path, path+".sha256")
+	}
+
+	var m Manifest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var fi FileInfo
+		if err := dec.Decode(&fi); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		m = append(m, fi)
+	}
+
+	sort.Slice(m, func(i, j int) bool { return m[i].RelativePath < m[j].RelativePath })
+	return m, nil
+}
+
+// DiffManifests compares old and new and reports which files were added,
+// removed, or modified. A file counts as modified if its hash changed
+// (when both manifests recorded a hash for it) or, absent hashes, if its
+// size or modification time changed.
+func DiffManifests(old, new Manifest) (added, removed, modified []FileInfo) {
+	oldByPath := make(map[string]FileInfo, len(old))
+	for _, fi := range old {
+		oldByPath[fi.RelativePath] = fi
+	}
+	seen := make(map[string]bool, len(new))
+
+	for _, fi := range new {
+		seen[fi.RelativePath] = true
+		prev, ok := oldByPath[fi.RelativePath]
+		if !ok {
+			added = append(added, fi)
+			continue
+		}
+		if fileChanged(prev, fi) {
+			modified = append(modified, fi)
+		}
+	}
+
+	for _, fi := range old {
+		if !seen[fi.RelativePath] {
+			removed = append(removed, fi)
+		}
+	}
+
+	return added, removed, modified
+}
+
+func fileChanged(old, new FileInfo) bool {
+	if old.Hash != "" && new.Hash != "" {
+		return old.Hash != new.Hash
+	}
+	return old.Size != new.Size || !old.ModTime.Equal(new.ModTime)
+}
+
+// WriteDiffCSV writes a human-readable CSV report of a manifest diff to
+// path: one row per changed file, as status,relative_path,size,mod_time.
+func WriteDiffCSV(path string, added, removed, modified []FileInfo) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	defer w.Flush()
+
+	if err := w.Write([]string{"status", "relative_path", "size", "mod_time"}); err != nil {
+		return err
+	}
+
+	groups := []struct {
+		status string
+		files  []FileInfo
+	}{
+		{"added", added},
+		{"removed", removed},
+		{"modified", modified},
+	}
+	for _, g := range groups {
+		for _, fi := range g.files {
+			row := []string{g.status, fi.RelativePath, fmt.Sprintf("%d", fi.Size), fi.ModTime.Format(time.RFC3339)}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.Error()
+}
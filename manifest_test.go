@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileChangedByHash(t *testing.T) {
+	base := time.Now()
+	old := FileInfo{RelativePath: "a", Size: 10, ModTime: base, Hash: "aaa"}
+
+	// Hash differs: modified, even though size and mtime look unchanged.
+	if !fileChanged(old, FileInfo{RelativePath: "a", Size: 10, ModTime: base, Hash: "bbb"}) {
+		t.Error("fileChanged() = false, want true when hashes differ")
+	}
+	// Hash matches: unmodified, even though size claims to differ (hash wins).
+	if fileChanged(old, FileInfo{RelativePath: "a", Size: 999, ModTime: base, Hash: "aaa"}) {
+		t.Error("fileChanged() = true, want false when hashes match")
+	}
+}
+
+func TestFileChangedFallsBackToSizeAndModTime(t *testing.T) {
+	base := time.Now()
+	old := FileInfo{RelativePath: "a", Size: 10, ModTime: base}
+
+	if fileChanged(old, FileInfo{RelativePath: "a", Size: 10, ModTime: base}) {
+		t.Error("fileChanged() = true, want false when size and mtime are unchanged and no hash is recorded")
+	}
+	if !fileChanged(old, FileInfo{RelativePath: "a", Size: 11, ModTime: base}) {
+		t.Error("fileChanged() = false, want true when size differs and no hash is recorded")
+	}
+	if !fileChanged(old, FileInfo{RelativePath: "a", Size: 10, ModTime: base.Add(time.Second)}) {
+		t.Error("fileChanged() = false, want true when mtime differs and no hash is recorded")
+	}
+}
+
+func TestDiffManifests(t *testing.T) {
+	base := time.Now()
+	old := Manifest{
+		{RelativePath: "keep.txt", Size: 1, ModTime: base, Hash: "h1"},
+		{RelativePath: "edit.txt", Size: 2, ModTime: base, Hash: "h2"},
+		{RelativePath: "gone.txt", Size: 3, ModTime: base, Hash: "h3"},
+	}
+	new := Manifest{
+		{RelativePath: "keep.txt", Size: 1, ModTime: base, Hash: "h1"},
+		{RelativePath: "edit.txt", Size: 2, ModTime: base, Hash: "h2-changed"},
+		{RelativePath: "new.txt", Size: 4, ModTime: base, Hash: "h4"},
+	}
+
+	added, removed, modified := DiffManifests(old, new)
+
+	if len(added) != 1 || added[0].RelativePath != "new.txt" {
+		t.Errorf("added = %v, want [new.txt]", added)
+	}
+	if len(removed) != 1 || removed[0].RelativePath != "gone.txt" {
+		t.Errorf("removed = %v, want [gone.txt]", removed)
+	}
+	if len(modified) != 1 || modified[0].RelativePath != "edit.txt" {
+		t.Errorf("modified = %v, want [edit.txt]", modified)
+	}
+}
+
+func TestSaveLoadManifestRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.manifest")
+	want := Manifest{
+		{RelativePath: "a.txt", Size: 1, ModTime: time.Now(), Hash: "h1"},
+		{RelativePath: "b.txt", Size: 2, ModTime: time.Now(), Hash: "h2"},
+	}
+
+	if err := SaveManifest(path, want); err != nil {
+		t.Fatalf("SaveManifest() error = %v", err)
+	}
+
+	got, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("LoadManifest() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].RelativePath != want[i].RelativePath || got[i].Hash != want[i].Hash {
+			t.Errorf("LoadManifest()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoadManifestMissingIsNotAnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "does-not-exist.manifest")
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v, want nil for a manifest that was never written", err)
+	}
+	if m != nil {
+		t.Errorf("LoadManifest() = %v, want nil", m)
+	}
+}
+
+func TestLoadManifestDetectsCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.manifest")
+	if err := SaveManifest(path, Manifest{{RelativePath: "a.txt", Size: 1, ModTime: time.Now(), Hash: "h1"}}); err != nil {
+		t.Fatalf("SaveManifest() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadManifest(path); err == nil {
+		t.Error("LoadManifest() error = nil, want an error for a manifest that no longer matches its .sha256 sidecar")
+	}
+}
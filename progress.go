@@ -0,0 +1,19 @@
+package main
+
+// Progress receives updates as a file is copied, so a future TUI or HTTP
+// status page can subscribe to transfer state without AddBackup knowing
+// anything about how progress is displayed.
+type Progress interface {
+	// OnChunk is called after each chunk is written, with the number of
+	// bytes written so far and the total size of the file being copied.
+	OnChunk(path string, written, total int64)
+	// OnRetry is called before a chunk transfer is retried.
+	OnRetry(path string, attempt int, err error)
+}
+
+// noopProgress discards every update. It is the default when no Progress
+// is supplied.
+type noopProgress struct{}
+
+func (noopProgress) OnChunk(path string, written, total int64) {}
+func (noopProgress) OnRetry(path string, attempt int, err error) {}
@@ -0,0 +1,51 @@
+package main
+
+import "errors"
+
+// CopyOptions controls how a backup copy of a file is made.
+type CopyOptions struct {
+	// PreferReflink attempts a copy-on-write clone before falling back to
+	// a byte-for-byte streaming copy. It only has an effect when src and
+	// dst live on the same volume and that volume supports reflinks.
+	PreferReflink bool
+}
+
+// errReflinkUnsupported is returned by a platform's reflinkCopy when the
+// clone could not be performed for a reason that should fall back to a
+// streaming copy (EXDEV, ENOTSUP, or equivalent) rather than fail outright.
+var errReflinkUnsupported = errors.New("reflink: not supported")
+
+// copyFileWithOptions copies src to dst according to opts. reflinked
+// reports whether a copy-on-write clone was used: a reflinked copy shares
+// storage with src and its hash is left empty since the clone is, by
+// definition, byte-identical to the source at clone time. Otherwise hash is
+// the SHA-256 of the bytes streamed during a regular chunked copy.
+func copyFileWithOptions(src, dst string, opts CopyOptions) (hash string, reflinked bool, err error) {
+	if opts.PreferReflink {
+		st, err := loadPartState(dst)
+		if err != nil {
+			return "", false, err
+		}
+		// A .part sidecar means a previous chunked copy left real bytes
+		// (and an incremental hash) at dst. reflinkCopy truncates or
+		// removes dst before cloning, so attempting it here would corrupt
+		// the resume: copyFileChunked would go on to trust BytesWritten
+		// and write into what is now a hole, yet still produce a hash
+		// that matches expectedHash because the hash state survived the
+		// destruction of the data it was computed over. Skip straight to
+		// the chunked copy so it resumes against the bytes it expects.
+		if st == nil || st.DestPath != dst {
+			if err := reflinkCopy(src, dst); err == nil {
+				return "", true, nil
+			} else if !errors.Is(err, errReflinkUnsupported) {
+				return "", false, err
+			}
+		}
+	}
+
+	hash, err = copyFileChunked(src, dst, defaultChunkSize, nil)
+	if err != nil {
+		return "", false, err
+	}
+	return hash, false, nil
+}
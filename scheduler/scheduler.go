@@ -0,0 +1,64 @@
+// Package scheduler runs Sinki Backups' recurring jobs on a cron spec per
+// storage row, so main can run as a long-lived daemon instead of a
+// one-shot command.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Job is one recurring unit of work, fired whenever Spec matches.
+type Job struct {
+	Name string
+	Spec string
+	Run  func(ctx context.Context)
+}
+
+// Scheduler wraps a cron.Cron, tracking the entries it registered so a
+// config reload can clear and re-register jobs without restarting.
+type Scheduler struct {
+	cron    *cron.Cron
+	logger  *slog.Logger
+	entries []cron.EntryID
+}
+
+// New returns a Scheduler that logs every job run through logger.
+func New(logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		cron:   cron.New(),
+		logger: logger,
+	}
+}
+
+// Reload clears every previously registered job and registers jobs anew.
+// It's safe to call while the scheduler is running, which is what lets a
+// SIGHUP-triggered config reload change schedules without restarting.
+func (s *Scheduler) Reload(ctx context.Context, jobs []Job) error {
+	for _, id := range s.entries {
+		s.cron.Remove(id)
+	}
+	s.entries = s.entries[:0]
+
+	for _, job := range jobs {
+		job := job
+		id, err := s.cron.AddFunc(job.Spec, func() {
+			s.logger.Info("scheduler.run", "job", job.Name)
+			job.Run(ctx)
+		})
+		if err != nil {
+			return err
+		}
+		s.entries = append(s.entries, id)
+	}
+
+	return nil
+}
+
+// Start runs the scheduler in the background.
+func (s *Scheduler) Start() { s.cron.Start() }
+
+// Stop waits for any in-flight job to finish, then stops the scheduler.
+func (s *Scheduler) Stop() { <-s.cron.Stop().Done() }